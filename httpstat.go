@@ -5,11 +5,33 @@ package httpstat
 import (
 	"bytes"
 	"context"
+	"crypto/x509"
 	"fmt"
+	"net"
+	"net/http"
 	"strconv"
+	"sync"
 	"time"
 )
 
+// ConnectAttempt records timing for a single connect attempt made while
+// establishing a connection. When a host resolves to several addresses
+// (happy eyeballs, or a plain IPv6-then-IPv4 fallback), httptrace fires
+// ConnectStart/ConnectDone once per address tried, so there can be more
+// than one of these per Result.
+type ConnectAttempt struct {
+	Network string
+	Addr    string
+	Start   time.Time
+	End     time.Time
+	Err     error
+
+	// TLSStart and TLSDone are only set when the attempt is the one that
+	// the TLS handshake ran on top of.
+	TLSStart time.Time
+	TLSDone  time.Time
+}
+
 // Result stores httpstat info.
 type Result struct {
 	// The following are duration for each phase
@@ -26,6 +48,44 @@ type Result struct {
 	StartTransfer time.Duration
 	total         time.Duration
 
+	// Attempts records every connect attempt made while establishing the
+	// connection used for this request, in the order httptrace reported
+	// them.
+	//
+	// Happy-eyeballs can fire ConnectStart/ConnectDone for several
+	// addresses from different goroutines at once, so Attempts and
+	// connAttempt are guarded by the *sync.Mutex held in attemptsMu
+	// rather than assumed to be single-goroutine like the rest of
+	// Result. attemptsMu is set up once in OnGetConn, which always runs
+	// synchronously before the dialer spawns any happy-eyeballs
+	// goroutines, so those goroutines see it correctly initialized
+	// through the happens-before edge a go statement already gives its
+	// goroutine - no atomic handoff needed. lockAttempts falls back to
+	// the attemptsMuInit global mutex only for callers that drive the
+	// Observer directly without going through OnGetConn first. Plain
+	// pointer copies are safe even though Result itself is copied by
+	// value elsewhere (Trace.Hops, Format, the Marshal methods); only
+	// the mutex is shared, not the slice it guards.
+	Attempts   []ConnectAttempt
+	attemptsMu *sync.Mutex
+
+	// DNSAddrs is the set of addresses the DNS lookup resolved to, and
+	// DNSCoalesced reports whether the lookup was shared with another
+	// in-flight lookup for the same host.
+	DNSAddrs     []net.IPAddr
+	DNSCoalesced bool
+
+	// connAttempt maps the addr passed to ConnectStart to its index in
+	// Attempts, so the matching ConnectDone (and, if it applies, the TLS
+	// handshake) can be recorded against the right entry.
+	connAttempt map[string]int
+
+	// tlsAttemptIdx is the index into Attempts that the TLS handshake
+	// (if any) ran on top of, set once the connect attempt it belongs to
+	// succeeds.
+	tlsAttemptIdx int
+	hasTLSAttempt bool
+
 	t0 time.Time
 	t1 time.Time
 	t2 time.Time
@@ -49,6 +109,68 @@ type Result struct {
 
 	// isReused is true when connection is reused (keep-alive)
 	isReused bool
+
+	// TLSVersion, TLSCipherSuite, NegotiatedProtocol and ServerName come
+	// from tls.ConnectionState once the handshake completes. Proto is
+	// go-httpstat's best guess at the resulting HTTP protocol version,
+	// derived from NegotiatedProtocol (ALPN); it is always "HTTP/1.1" for
+	// plaintext connections.
+	TLSVersion         uint16
+	TLSCipherSuite     uint16
+	NegotiatedProtocol string
+	ServerName         string
+	PeerCertificates   []*x509.Certificate
+	Proto              string
+
+	// RemoteAddr and LocalAddr are the two ends of the connection the
+	// request was sent over.
+	RemoteAddr string
+	LocalAddr  string
+
+	// URL is the request URL this Result was recorded for. It is only
+	// populated when the Result is a hop of a Trace (see
+	// WithHTTPTrace); a bare Result created via WithHTTPStat leaves it
+	// empty, since httptrace never hands the URL to its hooks.
+	URL string
+
+	// ConnAcquire is the time spent waiting for a connection (from
+	// GetConn to GotConn), which includes idle-conn reuse as well as
+	// dialing a new one. WasIdle and IdleTime come straight from
+	// GotConnInfo when the acquired connection was reused.
+	ConnAcquire time.Duration
+	WasIdle     bool
+	IdleTime    time.Duration
+
+	// PutIdleConnErr is the error (if any) returned when the connection
+	// was given back to the idle pool. It is nil when the connection was
+	// kept alive successfully, or when PutIdleConn was never called
+	// (e.g. HTTP/2, or keep-alive disabled).
+	PutIdleConnErr error
+
+	// Wait100 is how long the client waited for a "100 Continue"
+	// response after writing the request headers. It is zero unless the
+	// request set "Expect: 100-continue".
+	Wait100 time.Duration
+
+	// Informational records every 1xx response the server sent before
+	// the final response.
+	Informational []InformationalResponse
+
+	// RequestWrite is the time spent writing the request (from GetConn
+	// to WroteRequest), which includes time spent waiting for a
+	// connection.
+	RequestWrite time.Duration
+
+	getConnStart time.Time
+	wait100Start time.Time
+}
+
+// InformationalResponse is a single 1xx response the server sent before
+// the final response, as reported by httptrace.ClientTrace.Got1xxResponse.
+type InformationalResponse struct {
+	Code   int         `json:"code"`
+	Header http.Header `json:"header"`
+	At     time.Time   `json:"at"`
 }
 
 func (r *Result) durations() map[string]time.Duration {
@@ -64,21 +186,12 @@ func (r *Result) durations() map[string]time.Duration {
 		"Pretransfer":   r.Connect,
 		"StartTransfer": r.StartTransfer,
 		"Total":         r.total,
-	}
-}
-
-// ContentTransfer returns the duration of content transfer time.
-// It is from first response byte to the given time. The time must
-// be time after read body (go-httpstat can not detect that time).
-func (r *Result) ContentTransfer(t time.Time) time.Duration {
-	return t.Sub(r.t4)
-}
 
-// Total returns the duration of total http request.
-// It is from dns lookup start time to the given time. The
-// time must be time after read body (go-httpstat can not detect that time).
-func (r *Result) Total(t time.Time) time.Duration {
-	return t.Sub(r.t0)
+		"ConnAcquire":  r.ConnAcquire,
+		"IdleTime":     r.IdleTime,
+		"Wait100":      r.Wait100,
+		"RequestWrite": r.RequestWrite,
+	}
 }
 
 // Format formats stats result.
@@ -117,6 +230,37 @@ func (r Result) Format(s fmt.State, verb rune) {
 			} else {
 				fmt.Fprintf(s, "Total:          %4s ms\n", "-")
 			}
+
+			if r.Proto != "" {
+				fmt.Fprintf(s, "\nProtocol:       %s\n", r.Proto)
+			}
+			if r.NegotiatedProtocol != "" {
+				fmt.Fprintf(s, "ALPN:           %s\n", r.NegotiatedProtocol)
+			}
+			if r.isTLS {
+				fmt.Fprintf(s, "TLS version:    %#x\n", r.TLSVersion)
+				fmt.Fprintf(s, "TLS cipher:     %#x\n", r.TLSCipherSuite)
+			}
+
+			if r.ConnAcquire > 0 {
+				fmt.Fprintf(s, "\nConn acquire:   %4d ms\n",
+					int(r.ConnAcquire/time.Millisecond))
+			}
+			if r.WasIdle {
+				fmt.Fprintf(s, "Conn idle time: %4d ms\n",
+					int(r.IdleTime/time.Millisecond))
+			}
+			if r.Wait100 > 0 {
+				fmt.Fprintf(s, "Wait 100:       %4d ms\n",
+					int(r.Wait100/time.Millisecond))
+			}
+			if r.RequestWrite > 0 {
+				fmt.Fprintf(s, "Request write:  %4d ms\n",
+					int(r.RequestWrite/time.Millisecond))
+			}
+			for _, info := range r.Informational {
+				fmt.Fprintf(s, "Informational:  %d\n", info.Code)
+			}
 			return
 		}
 
@@ -2,11 +2,15 @@ package httpstat
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
 	"testing"
 	"time"
 )
@@ -68,7 +72,26 @@ func TestHTTPStat_HTTPS(t *testing.T) {
 		t.Fatal("isTLS should be true")
 	}
 
-	for k, d := range result.durations() {
+	if result.TLSVersion == 0 {
+		t.Fatal("expect TLSVersion to be non-zero")
+	}
+	if result.Proto == "" {
+		t.Fatal("expect Proto to be set")
+	}
+	if result.RemoteAddr == "" {
+		t.Fatal("expect RemoteAddr to be set")
+	}
+	if result.LocalAddr == "" {
+		t.Fatal("expect LocalAddr to be set")
+	}
+
+	// IdleTime and Wait100 are legitimately zero: the connection is fresh
+	// (not reused) and the request doesn't use Expect: 100-continue.
+	durations := result.durations()
+	delete(durations, "IdleTime")
+	delete(durations, "Wait100")
+
+	for k, d := range durations {
 		if d <= 0*time.Millisecond {
 			t.Fatalf("expect %s to be non-zero", k)
 		}
@@ -99,9 +122,16 @@ func TestHTTPStat_HTTP(t *testing.T) {
 		t.Fatalf("TLSHandshake time of HTTP = %d, want %d", got, want)
 	}
 
-	// Except TLS should be non zero
+	if got, want := result.Proto, "HTTP/1.1"; got != want {
+		t.Fatalf("Proto of HTTP = %s, want %s", got, want)
+	}
+
+	// Except TLS should be non zero. IdleTime and Wait100 are also
+	// legitimately zero for the same reason as in TestHTTPStat_HTTPS.
 	durations := result.durations()
 	delete(durations, "TLSHandshake")
+	delete(durations, "IdleTime")
+	delete(durations, "Wait100")
 
 	for k, d := range durations {
 		if d <= 0*time.Millisecond {
@@ -111,10 +141,8 @@ func TestHTTPStat_HTTP(t *testing.T) {
 }
 
 func TestHTTPStat_KeepAlive(t *testing.T) {
-	req1, err := http.NewRequest("GET", TestDomainHTTPS, nil)
-	if err != nil {
-		t.Fatal("NewRequest failed:", err)
-	}
+	var result1 Result
+	req1 := NewRequest(t, TestDomainHTTPS, &result1)
 
 	client := DefaultClient()
 	res1, err := client.Do(req1)
@@ -126,6 +154,7 @@ func TestHTTPStat_KeepAlive(t *testing.T) {
 		t.Fatal("Copy body failed:", err)
 	}
 	res1.Body.Close()
+	result1.End(time.Now())
 
 	var result Result
 	req2 := NewRequest(t, TestDomainHTTPS, &result)
@@ -155,6 +184,13 @@ func TestHTTPStat_KeepAlive(t *testing.T) {
 			t.Fatalf("#%d expect %d to be eq %d", i, got, want)
 		}
 	}
+
+	// The reused connection never re-runs the TLS handshake, so Proto
+	// must come from what the first request on this connection
+	// negotiated, not a hardcoded guess.
+	if got, want := result.Proto, result1.Proto; got != want {
+		t.Fatalf("expect reused connection Proto to be %q, got %q", want, got)
+	}
 }
 
 func TestHTTPStat_beforeGO17(t *testing.T) {
@@ -200,6 +236,139 @@ func TestHTTPStat_beforeGO17(t *testing.T) {
 	}
 }
 
+func TestHTTPStat_MultipleConnectAttempts(t *testing.T) {
+	var result Result
+	ctx := WithHTTPStat(context.Background(), &result)
+	trace := httptrace.ContextClientTrace(ctx)
+
+	// Simulate a happy-eyeballs style fallback: the first address fails
+	// and the dialer retries on a second one.
+	trace.ConnectStart("tcp", "[::1]:443")
+	trace.ConnectDone("tcp", "[::1]:443", fmt.Errorf("connection refused"))
+	trace.ConnectStart("tcp", "127.0.0.1:443")
+	trace.ConnectDone("tcp", "127.0.0.1:443", nil)
+
+	if got, want := len(result.Attempts), 2; got != want {
+		t.Fatalf("len(Attempts) = %d, want %d", got, want)
+	}
+
+	if got, want := result.Attempts[0].Addr, "[::1]:443"; got != want {
+		t.Fatalf("Attempts[0].Addr = %s, want %s", got, want)
+	}
+	if result.Attempts[0].Err == nil {
+		t.Fatal("expect Attempts[0].Err to be non-nil")
+	}
+
+	if got, want := result.Attempts[1].Addr, "127.0.0.1:443"; got != want {
+		t.Fatalf("Attempts[1].Addr = %s, want %s", got, want)
+	}
+	if result.Attempts[1].Err != nil {
+		t.Fatalf("expect Attempts[1].Err to be nil, got %v", result.Attempts[1].Err)
+	}
+}
+
+// fakeAddr and fakeConn let tests control GotConnInfo.Conn's RemoteAddr
+// without dialing a real connection.
+type fakeAddr string
+
+func (a fakeAddr) Network() string { return "tcp" }
+func (a fakeAddr) String() string  { return string(a) }
+
+type fakeConn struct {
+	net.Conn
+	remoteAddr string
+}
+
+func (c fakeConn) RemoteAddr() net.Addr { return fakeAddr(c.remoteAddr) }
+func (c fakeConn) LocalAddr() net.Addr  { return fakeAddr("127.0.0.1:0") }
+
+// fakeTLSConn additionally implements ConnectionState, the way *tls.Conn
+// does, so tests can simulate GotConn reading a reused connection's real
+// negotiated protocol straight off it.
+type fakeTLSConn struct {
+	fakeConn
+	state tls.ConnectionState
+}
+
+func (c fakeTLSConn) ConnectionState() tls.ConnectionState { return c.state }
+
+func TestHTTPStat_ReusedConnectionProto(t *testing.T) {
+	const addr = "127.0.0.1:443"
+
+	// First request: a fresh connection negotiates HTTP/2 over TLS.
+	var result1 Result
+	trace1 := httptrace.ContextClientTrace(WithHTTPStat(context.Background(), &result1))
+
+	trace1.ConnectStart("tcp", addr)
+	trace1.ConnectDone("tcp", addr, nil)
+	trace1.TLSHandshakeStart()
+	trace1.TLSHandshakeDone(tls.ConnectionState{NegotiatedProtocol: "h2"}, nil)
+	trace1.GotConn(httptrace.GotConnInfo{Conn: fakeConn{remoteAddr: addr}})
+
+	if got, want := result1.Proto, "HTTP/2.0"; got != want {
+		t.Fatalf("result1.Proto = %s, want %s", got, want)
+	}
+
+	// Second request reuses a connection to the same address, but one
+	// that belongs to a different host that happens to share it (e.g.
+	// behind a load balancer) and negotiated plain HTTP/1.1 on its own.
+	// Proto must come from that connection's own state, not from
+	// whatever the first, unrelated request happened to negotiate.
+	var result2 Result
+	trace2 := httptrace.ContextClientTrace(WithHTTPStat(context.Background(), &result2))
+
+	trace2.GotConn(httptrace.GotConnInfo{
+		Reused: true,
+		Conn: fakeTLSConn{
+			fakeConn: fakeConn{remoteAddr: addr},
+			state:    tls.ConnectionState{NegotiatedProtocol: "http/1.1"},
+		},
+	})
+
+	if got, want := result2.Proto, "HTTP/1.1"; got != want {
+		t.Fatalf("reused result.Proto = %s, want %s", got, want)
+	}
+}
+
+func TestHTTPStat_ConnAcquireAndInformational(t *testing.T) {
+	var result Result
+	ctx := WithHTTPStat(context.Background(), &result)
+	trace := httptrace.ContextClientTrace(ctx)
+
+	trace.GetConn("example.com:443")
+	trace.Wait100Continue()
+	trace.Got1xxResponse(100, textproto.MIMEHeader{})
+	trace.Got100Continue()
+	trace.GotConn(httptrace.GotConnInfo{WasIdle: true, IdleTime: 5 * time.Second})
+	trace.WroteRequest(httptrace.WroteRequestInfo{})
+	trace.PutIdleConn(nil)
+
+	if result.ConnAcquire <= 0 {
+		t.Fatal("expect ConnAcquire to be non-zero")
+	}
+	if result.RequestWrite <= 0 {
+		t.Fatal("expect RequestWrite to be non-zero")
+	}
+	if !result.WasIdle {
+		t.Fatal("expect WasIdle to be true")
+	}
+	if got, want := result.IdleTime, 5*time.Second; got != want {
+		t.Fatalf("IdleTime = %d, want %d", got, want)
+	}
+	if result.Wait100 <= 0 {
+		t.Fatal("expect Wait100 to be non-zero")
+	}
+	if got, want := len(result.Informational), 1; got != want {
+		t.Fatalf("len(Informational) = %d, want %d", got, want)
+	}
+	if got, want := result.Informational[0].Code, 100; got != want {
+		t.Fatalf("Informational[0].Code = %d, want %d", got, want)
+	}
+	if result.PutIdleConnErr != nil {
+		t.Fatalf("expect PutIdleConnErr to be nil, got %v", result.PutIdleConnErr)
+	}
+}
+
 func TestTotal_Zero(t *testing.T) {
 	result := &Result{}
 	result.End(time.Now())
@@ -0,0 +1,350 @@
+package httpstat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// jsonDuration renders a time.Duration as both its raw nanosecond count
+// and a millisecond convenience value, so a consumer of Result's JSON
+// doesn't have to do the division itself.
+type jsonDuration time.Duration
+
+func (d jsonDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Ns int64 `json:"ns"`
+		Ms int64 `json:"ms"`
+	}{
+		Ns: int64(d),
+		Ms: int64(time.Duration(d) / time.Millisecond),
+	})
+}
+
+func (d *jsonDuration) UnmarshalJSON(b []byte) error {
+	var v struct {
+		Ns int64 `json:"ns"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*d = jsonDuration(v.Ns)
+	return nil
+}
+
+// connectAttemptJSON is the wire format for ConnectAttempt. Err is
+// flattened to its message, since the error interface itself doesn't
+// round-trip through JSON.
+type connectAttemptJSON struct {
+	Network  string    `json:"network"`
+	Addr     string    `json:"addr"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Err      string    `json:"err,omitempty"`
+	TLSStart time.Time `json:"tls_start,omitempty"`
+	TLSDone  time.Time `json:"tls_done,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (a ConnectAttempt) MarshalJSON() ([]byte, error) {
+	j := connectAttemptJSON{
+		Network:  a.Network,
+		Addr:     a.Addr,
+		Start:    a.Start,
+		End:      a.End,
+		TLSStart: a.TLSStart,
+		TLSDone:  a.TLSDone,
+	}
+	if a.Err != nil {
+		j.Err = a.Err.Error()
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Err comes back as a plain
+// error built from its message, not the original error value or type.
+func (a *ConnectAttempt) UnmarshalJSON(b []byte) error {
+	var j connectAttemptJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+
+	a.Network = j.Network
+	a.Addr = j.Addr
+	a.Start = j.Start
+	a.End = j.End
+	a.TLSStart = j.TLSStart
+	a.TLSDone = j.TLSDone
+	if j.Err != "" {
+		a.Err = fmt.Errorf("%s", j.Err)
+	}
+	return nil
+}
+
+// resultJSON is the wire format for Result. It covers every exported
+// field except PeerCertificates, which is large and rarely what a log
+// consumer wants; callers that need the full chain can still reach it
+// through Result itself.
+type resultJSON struct {
+	DNSLookup        jsonDuration `json:"dns_lookup"`
+	TCPConnection    jsonDuration `json:"tcp_connection"`
+	TLSHandshake     jsonDuration `json:"tls_handshake"`
+	ServerProcessing jsonDuration `json:"server_processing"`
+
+	NameLookup    jsonDuration `json:"name_lookup"`
+	Connect       jsonDuration `json:"connect"`
+	Pretransfer   jsonDuration `json:"pretransfer"`
+	StartTransfer jsonDuration `json:"start_transfer"`
+
+	Attempts     []ConnectAttempt `json:"attempts,omitempty"`
+	DNSAddrs     []net.IPAddr     `json:"dns_addrs,omitempty"`
+	DNSCoalesced bool             `json:"dns_coalesced,omitempty"`
+
+	TLSVersion         uint16 `json:"tls_version,omitempty"`
+	TLSCipherSuite     uint16 `json:"tls_cipher_suite,omitempty"`
+	NegotiatedProtocol string `json:"negotiated_protocol,omitempty"`
+	ServerName         string `json:"server_name,omitempty"`
+	Proto              string `json:"proto,omitempty"`
+
+	RemoteAddr string `json:"remote_addr,omitempty"`
+	LocalAddr  string `json:"local_addr,omitempty"`
+	URL        string `json:"url,omitempty"`
+
+	ConnAcquire    jsonDuration            `json:"conn_acquire,omitempty"`
+	WasIdle        bool                    `json:"was_idle,omitempty"`
+	IdleTime       jsonDuration            `json:"idle_time,omitempty"`
+	PutIdleConnErr string                  `json:"put_idle_conn_err,omitempty"`
+	Wait100        jsonDuration            `json:"wait_100,omitempty"`
+	Informational  []InformationalResponse `json:"informational,omitempty"`
+	RequestWrite   jsonDuration            `json:"request_write,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler. Durations are encoded as
+// nanoseconds plus a millisecond convenience value (see jsonDuration);
+// Content transfer and Total aren't included, even after End has been
+// called — take a Snapshot to record them.
+func (r Result) MarshalJSON() ([]byte, error) {
+	j := resultJSON{
+		DNSLookup:        jsonDuration(r.DNSLookup),
+		TCPConnection:    jsonDuration(r.TCPConnection),
+		TLSHandshake:     jsonDuration(r.TLSHandshake),
+		ServerProcessing: jsonDuration(r.ServerProcessing),
+
+		NameLookup:    jsonDuration(r.NameLookup),
+		Connect:       jsonDuration(r.Connect),
+		Pretransfer:   jsonDuration(r.Pretransfer),
+		StartTransfer: jsonDuration(r.StartTransfer),
+
+		Attempts:     r.Attempts,
+		DNSAddrs:     r.DNSAddrs,
+		DNSCoalesced: r.DNSCoalesced,
+
+		TLSVersion:         r.TLSVersion,
+		TLSCipherSuite:     r.TLSCipherSuite,
+		NegotiatedProtocol: r.NegotiatedProtocol,
+		ServerName:         r.ServerName,
+		Proto:              r.Proto,
+
+		RemoteAddr: r.RemoteAddr,
+		LocalAddr:  r.LocalAddr,
+		URL:        r.URL,
+
+		ConnAcquire:   jsonDuration(r.ConnAcquire),
+		WasIdle:       r.WasIdle,
+		IdleTime:      jsonDuration(r.IdleTime),
+		Wait100:       jsonDuration(r.Wait100),
+		Informational: r.Informational,
+		RequestWrite:  jsonDuration(r.RequestWrite),
+	}
+	if r.PutIdleConnErr != nil {
+		j.PutIdleConnErr = r.PutIdleConnErr.Error()
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It restores every field
+// MarshalJSON writes; PutIdleConnErr comes back as a plain error built
+// from its message, not the original error value or type.
+func (r *Result) UnmarshalJSON(b []byte) error {
+	var j resultJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+
+	r.DNSLookup = time.Duration(j.DNSLookup)
+	r.TCPConnection = time.Duration(j.TCPConnection)
+	r.TLSHandshake = time.Duration(j.TLSHandshake)
+	r.ServerProcessing = time.Duration(j.ServerProcessing)
+
+	r.NameLookup = time.Duration(j.NameLookup)
+	r.Connect = time.Duration(j.Connect)
+	r.Pretransfer = time.Duration(j.Pretransfer)
+	r.StartTransfer = time.Duration(j.StartTransfer)
+
+	r.Attempts = j.Attempts
+	r.DNSAddrs = j.DNSAddrs
+	r.DNSCoalesced = j.DNSCoalesced
+
+	r.TLSVersion = j.TLSVersion
+	r.TLSCipherSuite = j.TLSCipherSuite
+	r.NegotiatedProtocol = j.NegotiatedProtocol
+	r.ServerName = j.ServerName
+	r.Proto = j.Proto
+
+	r.RemoteAddr = j.RemoteAddr
+	r.LocalAddr = j.LocalAddr
+	r.URL = j.URL
+
+	r.ConnAcquire = time.Duration(j.ConnAcquire)
+	r.WasIdle = j.WasIdle
+	r.IdleTime = time.Duration(j.IdleTime)
+	if j.PutIdleConnErr != "" {
+		r.PutIdleConnErr = fmt.Errorf("%s", j.PutIdleConnErr)
+	}
+	r.Wait100 = time.Duration(j.Wait100)
+	r.Informational = j.Informational
+	r.RequestWrite = time.Duration(j.RequestWrite)
+
+	return nil
+}
+
+// MarshalText renders r as logfmt-style key=value pairs, durations in
+// milliseconds, for pipelines that want a single log line rather than a
+// JSON document. Content transfer and Total are omitted, as in
+// MarshalJSON; take a Snapshot first if you need them.
+func (r Result) MarshalText() ([]byte, error) {
+	var buf bytes.Buffer
+
+	writeMs := func(key string, d time.Duration) {
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		buf.WriteString(strconv.FormatInt(int64(d/time.Millisecond), 10))
+	}
+	writeStr := func(key, val string) {
+		if val == "" {
+			return
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		buf.WriteString(strconv.Quote(val))
+	}
+
+	writeMs("dns_lookup_ms", r.DNSLookup)
+	writeMs("tcp_connection_ms", r.TCPConnection)
+	writeMs("tls_handshake_ms", r.TLSHandshake)
+	writeMs("server_processing_ms", r.ServerProcessing)
+	writeStr("proto", r.Proto)
+	writeStr("negotiated_protocol", r.NegotiatedProtocol)
+	writeStr("remote_addr", r.RemoteAddr)
+	writeStr("local_addr", r.LocalAddr)
+	writeStr("url", r.URL)
+	if r.ConnAcquire > 0 {
+		writeMs("conn_acquire_ms", r.ConnAcquire)
+	}
+	if r.WasIdle {
+		writeMs("idle_time_ms", r.IdleTime)
+	}
+	if r.Wait100 > 0 {
+		writeMs("wait_100_ms", r.Wait100)
+	}
+	if r.PutIdleConnErr != nil {
+		writeStr("put_idle_conn_err", r.PutIdleConnErr.Error())
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Snapshot is a frozen, JSON-friendly view of a Result as of a given
+// time. Result.ContentTransfer and Result.Total need the caller to hand
+// back the time reading finished; a Snapshot records them once instead,
+// so it can be logged or stored without carrying that extra state
+// around. Take one with TakeSnapshot.
+type Snapshot struct {
+	DNSLookup        time.Duration `json:"dns_lookup_ns"`
+	TCPConnection    time.Duration `json:"tcp_connection_ns"`
+	TLSHandshake     time.Duration `json:"tls_handshake_ns"`
+	ServerProcessing time.Duration `json:"server_processing_ns"`
+	ContentTransfer  time.Duration `json:"content_transfer_ns"`
+
+	NameLookup    time.Duration `json:"name_lookup_ns"`
+	Connect       time.Duration `json:"connect_ns"`
+	Pretransfer   time.Duration `json:"pretransfer_ns"`
+	StartTransfer time.Duration `json:"start_transfer_ns"`
+	Total         time.Duration `json:"total_ns"`
+
+	Attempts     []ConnectAttempt `json:"attempts,omitempty"`
+	DNSAddrs     []net.IPAddr     `json:"dns_addrs,omitempty"`
+	DNSCoalesced bool             `json:"dns_coalesced,omitempty"`
+
+	TLSVersion         uint16 `json:"tls_version,omitempty"`
+	TLSCipherSuite     uint16 `json:"tls_cipher_suite,omitempty"`
+	NegotiatedProtocol string `json:"negotiated_protocol,omitempty"`
+	ServerName         string `json:"server_name,omitempty"`
+	Proto              string `json:"proto,omitempty"`
+
+	RemoteAddr string `json:"remote_addr,omitempty"`
+	LocalAddr  string `json:"local_addr,omitempty"`
+	URL        string `json:"url,omitempty"`
+
+	ConnAcquire   time.Duration           `json:"conn_acquire_ns,omitempty"`
+	WasIdle       bool                    `json:"was_idle,omitempty"`
+	IdleTime      time.Duration           `json:"idle_time_ns,omitempty"`
+	Wait100       time.Duration           `json:"wait_100_ns,omitempty"`
+	Informational []InformationalResponse `json:"informational,omitempty"`
+	RequestWrite  time.Duration           `json:"request_write_ns,omitempty"`
+
+	// At is the time the snapshot was taken, i.e. the t passed to
+	// TakeSnapshot.
+	At time.Time `json:"at"`
+}
+
+// TakeSnapshot freezes r's timings as of t, the same t that would
+// otherwise need to be passed to r.ContentTransfer and r.Total
+// separately.
+func TakeSnapshot(r *Result, t time.Time) Snapshot {
+	return Snapshot{
+		DNSLookup:        r.DNSLookup,
+		TCPConnection:    r.TCPConnection,
+		TLSHandshake:     r.TLSHandshake,
+		ServerProcessing: r.ServerProcessing,
+		ContentTransfer:  r.ContentTransfer(t),
+
+		NameLookup:    r.NameLookup,
+		Connect:       r.Connect,
+		Pretransfer:   r.Pretransfer,
+		StartTransfer: r.StartTransfer,
+		Total:         r.Total(t),
+
+		Attempts:     r.Attempts,
+		DNSAddrs:     r.DNSAddrs,
+		DNSCoalesced: r.DNSCoalesced,
+
+		TLSVersion:         r.TLSVersion,
+		TLSCipherSuite:     r.TLSCipherSuite,
+		NegotiatedProtocol: r.NegotiatedProtocol,
+		ServerName:         r.ServerName,
+		Proto:              r.Proto,
+
+		RemoteAddr: r.RemoteAddr,
+		LocalAddr:  r.LocalAddr,
+		URL:        r.URL,
+
+		ConnAcquire:   r.ConnAcquire,
+		WasIdle:       r.WasIdle,
+		IdleTime:      r.IdleTime,
+		Wait100:       r.Wait100,
+		Informational: r.Informational,
+		RequestWrite:  r.RequestWrite,
+
+		At: t,
+	}
+}
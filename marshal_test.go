@@ -0,0 +1,139 @@
+package httpstat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptrace"
+	"testing"
+	"time"
+)
+
+func TestResult_MarshalJSON_RoundTrip(t *testing.T) {
+	in := Result{
+		DNSLookup:     10 * time.Millisecond,
+		TCPConnection: 20 * time.Millisecond,
+		TLSHandshake:  30 * time.Millisecond,
+
+		Proto:              "HTTP/2.0",
+		NegotiatedProtocol: "h2",
+		RemoteAddr:         "127.0.0.1:443",
+		LocalAddr:          "127.0.0.1:54321",
+
+		Attempts: []ConnectAttempt{
+			{Network: "tcp", Addr: "127.0.0.1:443", Err: fmt.Errorf("boom")},
+		},
+		WasIdle:        true,
+		IdleTime:       5 * time.Second,
+		PutIdleConnErr: fmt.Errorf("connection closed"),
+
+		Informational: []InformationalResponse{
+			{Code: 103, At: time.Unix(0, 0).UTC()},
+		},
+	}
+
+	b, err := json.Marshal(&in)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var out Result
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if got, want := out.DNSLookup, in.DNSLookup; got != want {
+		t.Fatalf("DNSLookup = %v, want %v", got, want)
+	}
+	if got, want := out.Proto, in.Proto; got != want {
+		t.Fatalf("Proto = %s, want %s", got, want)
+	}
+	if got, want := len(out.Attempts), 1; got != want {
+		t.Fatalf("len(Attempts) = %d, want %d", got, want)
+	}
+	if got, want := out.Attempts[0].Err.Error(), "boom"; got != want {
+		t.Fatalf("Attempts[0].Err = %s, want %s", got, want)
+	}
+	if out.PutIdleConnErr == nil || out.PutIdleConnErr.Error() != "connection closed" {
+		t.Fatalf("PutIdleConnErr = %v, want %q", out.PutIdleConnErr, "connection closed")
+	}
+	if !out.WasIdle {
+		t.Fatal("expect WasIdle to round-trip true")
+	}
+	if got, want := len(out.Informational), 1; got != want {
+		t.Fatalf("len(Informational) = %d, want %d", got, want)
+	}
+	if got, want := out.Informational[0].Code, 103; got != want {
+		t.Fatalf("Informational[0].Code = %d, want %d", got, want)
+	}
+
+	// InformationalResponse must use the same snake_case schema as every
+	// other field in resultJSON.
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		t.Fatalf("Unmarshal into map failed: %v", err)
+	}
+	var infos []map[string]json.RawMessage
+	if err := json.Unmarshal(raw["informational"], &infos); err != nil {
+		t.Fatalf("Unmarshal informational failed: %v", err)
+	}
+	if _, ok := infos[0]["code"]; !ok {
+		t.Fatalf("informational[0] missing snake_case \"code\" key, got %v", infos[0])
+	}
+}
+
+func TestResult_MarshalText(t *testing.T) {
+	r := Result{
+		DNSLookup:     10 * time.Millisecond,
+		TCPConnection: 20 * time.Millisecond,
+		Proto:         "HTTP/1.1",
+		RemoteAddr:    "127.0.0.1:80",
+	}
+
+	b, err := r.MarshalText()
+	if err != nil {
+		t.Fatalf("MarshalText failed: %v", err)
+	}
+
+	got := string(b)
+	want := `dns_lookup_ms=10 tcp_connection_ms=20 tls_handshake_ms=0 server_processing_ms=0 proto="HTTP/1.1" remote_addr="127.0.0.1:80"`
+	if got != want {
+		t.Fatalf("MarshalText = %q, want %q", got, want)
+	}
+}
+
+func TestTakeSnapshot(t *testing.T) {
+	var r Result
+	ctx := WithHTTPStat(context.Background(), &r)
+	trace := httptrace.ContextClientTrace(ctx)
+
+	trace.DNSStart(httptrace.DNSStartInfo{})
+	trace.DNSDone(httptrace.DNSDoneInfo{})
+	trace.ConnectStart("tcp", "127.0.0.1:80")
+	trace.ConnectDone("tcp", "127.0.0.1:80", nil)
+	trace.GotFirstResponseByte()
+
+	now := time.Now()
+	snap := TakeSnapshot(&r, now)
+	if snap.At != now {
+		t.Fatalf("At = %v, want %v", snap.At, now)
+	}
+	if snap.Total <= 0 {
+		t.Fatalf("Total = %v, want > 0", snap.Total)
+	}
+	if snap.ContentTransfer <= 0 {
+		t.Fatalf("ContentTransfer = %v, want > 0", snap.ContentTransfer)
+	}
+
+	b, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if _, ok := decoded["total_ns"]; !ok {
+		t.Fatal("expect total_ns key in marshaled Snapshot")
+	}
+}
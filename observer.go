@@ -0,0 +1,518 @@
+// +build go1.8
+
+package httpstat
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Observer receives every httptrace event synchronously as it happens,
+// instead of requiring a caller to poll a Result once the request is
+// done. Implementations must return quickly: each On* method runs
+// straight from inside the underlying httptrace.ClientTrace hook, on
+// whatever goroutine the transport is using.
+//
+// Embed BaseObserver to pick up no-op defaults for the events you don't
+// care about.
+type Observer interface {
+	OnGetConn(GetConnEvent)
+	OnGotConn(GotConnEvent)
+	OnDNSStart(DNSStartEvent)
+	OnDNSDone(DNSDoneEvent)
+	OnConnectStart(ConnectStartEvent)
+	OnConnectDone(ConnectDoneEvent)
+	OnTLSHandshakeStart(TLSHandshakeStartEvent)
+	OnTLSHandshakeDone(TLSHandshakeDoneEvent)
+	OnWroteRequest(WroteRequestEvent)
+	OnPutIdleConn(PutIdleConnEvent)
+	OnWait100Continue(Wait100ContinueEvent)
+	OnGot100Continue(Got100ContinueEvent)
+	OnGot1xxResponse(Got1xxResponseEvent) error
+	OnFirstByte(FirstByteEvent)
+	OnEnd(EndEvent)
+}
+
+type GetConnEvent struct {
+	At       time.Time
+	HostPort string
+}
+
+type GotConnEvent struct {
+	At         time.Time
+	Reused     bool
+	WasIdle    bool
+	IdleTime   time.Duration
+	RemoteAddr string
+	LocalAddr  string
+
+	// Conn is the raw net.Conn GotConnInfo carried, for observers that
+	// need to introspect it (e.g. to read tls.ConnectionState off a
+	// reused connection, which never re-fires TLSHandshakeDone). It is
+	// nil if GotConnInfo didn't provide one.
+	Conn net.Conn
+}
+
+type DNSStartEvent struct {
+	At time.Time
+}
+
+type DNSDoneEvent struct {
+	At        time.Time
+	Addrs     []net.IPAddr
+	Coalesced bool
+	Err       error
+}
+
+type ConnectStartEvent struct {
+	At      time.Time
+	Network string
+	Addr    string
+}
+
+type ConnectDoneEvent struct {
+	At      time.Time
+	Network string
+	Addr    string
+	Err     error
+}
+
+type TLSHandshakeStartEvent struct {
+	At time.Time
+}
+
+type TLSHandshakeDoneEvent struct {
+	At    time.Time
+	State tls.ConnectionState
+	Err   error
+}
+
+type WroteRequestEvent struct {
+	At  time.Time
+	Err error
+}
+
+type PutIdleConnEvent struct {
+	At  time.Time
+	Err error
+}
+
+type Wait100ContinueEvent struct {
+	At time.Time
+}
+
+type Got100ContinueEvent struct {
+	At time.Time
+}
+
+type Got1xxResponseEvent struct {
+	At     time.Time
+	Code   int
+	Header http.Header
+}
+
+type FirstByteEvent struct {
+	At time.Time
+}
+
+type EndEvent struct {
+	At time.Time
+}
+
+// BaseObserver implements Observer with a no-op for every method, so
+// callers can embed it and override only the events they care about.
+type BaseObserver struct{}
+
+func (BaseObserver) OnGetConn(GetConnEvent)                     {}
+func (BaseObserver) OnGotConn(GotConnEvent)                     {}
+func (BaseObserver) OnDNSStart(DNSStartEvent)                   {}
+func (BaseObserver) OnDNSDone(DNSDoneEvent)                     {}
+func (BaseObserver) OnConnectStart(ConnectStartEvent)           {}
+func (BaseObserver) OnConnectDone(ConnectDoneEvent)             {}
+func (BaseObserver) OnTLSHandshakeStart(TLSHandshakeStartEvent) {}
+func (BaseObserver) OnTLSHandshakeDone(TLSHandshakeDoneEvent)   {}
+func (BaseObserver) OnWroteRequest(WroteRequestEvent)           {}
+func (BaseObserver) OnPutIdleConn(PutIdleConnEvent)             {}
+func (BaseObserver) OnWait100Continue(Wait100ContinueEvent)     {}
+func (BaseObserver) OnGot100Continue(Got100ContinueEvent)       {}
+func (BaseObserver) OnGot1xxResponse(Got1xxResponseEvent) error { return nil }
+func (BaseObserver) OnFirstByte(FirstByteEvent)                 {}
+func (BaseObserver) OnEnd(EndEvent)                             {}
+
+// WithHTTPStatObserver is a wrapper of httptrace.WithClientTrace that
+// fires obs's methods synchronously as each trace event happens, instead
+// of collecting them into a Result. Call EndObserve once the response
+// body has been read to fire the final OnEnd event, the same way
+// Result.End is called for WithHTTPStat.
+func WithHTTPStatObserver(ctx context.Context, obs Observer) context.Context {
+	ct := &httptrace.ClientTrace{
+		GetConn: func(hostPort string) {
+			obs.OnGetConn(GetConnEvent{At: time.Now(), HostPort: hostPort})
+		},
+
+		GotConn: func(i httptrace.GotConnInfo) {
+			e := GotConnEvent{
+				At:       time.Now(),
+				Reused:   i.Reused,
+				WasIdle:  i.WasIdle,
+				IdleTime: i.IdleTime,
+			}
+			if i.Conn != nil {
+				e.RemoteAddr = i.Conn.RemoteAddr().String()
+				e.LocalAddr = i.Conn.LocalAddr().String()
+				e.Conn = i.Conn
+			}
+			obs.OnGotConn(e)
+		},
+
+		DNSStart: func(i httptrace.DNSStartInfo) {
+			obs.OnDNSStart(DNSStartEvent{At: time.Now()})
+		},
+
+		DNSDone: func(i httptrace.DNSDoneInfo) {
+			obs.OnDNSDone(DNSDoneEvent{At: time.Now(), Addrs: i.Addrs, Coalesced: i.Coalesced, Err: i.Err})
+		},
+
+		ConnectStart: func(network, addr string) {
+			obs.OnConnectStart(ConnectStartEvent{At: time.Now(), Network: network, Addr: addr})
+		},
+
+		ConnectDone: func(network, addr string, err error) {
+			obs.OnConnectDone(ConnectDoneEvent{At: time.Now(), Network: network, Addr: addr, Err: err})
+		},
+
+		TLSHandshakeStart: func() {
+			obs.OnTLSHandshakeStart(TLSHandshakeStartEvent{At: time.Now()})
+		},
+
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			obs.OnTLSHandshakeDone(TLSHandshakeDoneEvent{At: time.Now(), State: state, Err: err})
+		},
+
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			obs.OnWroteRequest(WroteRequestEvent{At: time.Now(), Err: info.Err})
+		},
+
+		PutIdleConn: func(err error) {
+			obs.OnPutIdleConn(PutIdleConnEvent{At: time.Now(), Err: err})
+		},
+
+		Wait100Continue: func() {
+			obs.OnWait100Continue(Wait100ContinueEvent{At: time.Now()})
+		},
+
+		Got100Continue: func() {
+			obs.OnGot100Continue(Got100ContinueEvent{At: time.Now()})
+		},
+
+		GotFirstResponseByte: func() {
+			obs.OnFirstByte(FirstByteEvent{At: time.Now()})
+		},
+	}
+
+	// Got1xxResponse was only added to httptrace.ClientTrace in Go 1.11;
+	// referencing it directly in this composite literal would break the
+	// go1.8 build this file otherwise supports. setGot1xxResponse is
+	// defined per Go version in observer_go111.go/observer_pre_go111.go.
+	setGot1xxResponse(ct, obs)
+
+	return httptrace.WithClientTrace(ctx, ct)
+}
+
+// EndObserve fires obs's OnEnd event. Call it after reading the response
+// body, the same way Result.End is called.
+func EndObserve(obs Observer, t time.Time) {
+	obs.OnEnd(EndEvent{At: t})
+}
+
+// ResultObserver is an Observer that fills in a Result. WithHTTPStat is
+// built on top of it, so the streaming Observer API and the original
+// poll-a-Result API share one implementation of what each trace event
+// means.
+type ResultObserver struct {
+	Result *Result
+}
+
+// tlsConnectionStater is implemented by *tls.Conn (and anything else that
+// exposes its negotiated state the same way). OnGotConn type-asserts
+// GotConnEvent.Conn against it to read the real tls.ConnectionState off a
+// reused connection, since TLSHandshakeStart/Done only fire once per
+// connection, not once per request.
+type tlsConnectionStater interface {
+	ConnectionState() tls.ConnectionState
+}
+
+// attemptsMuInit serializes the lazy creation of a Result's attemptsMu for
+// callers that drive the Observer directly without going through OnGetConn
+// first (as some of this package's own tests do). Every real dial goes
+// through OnGetConn, which sets attemptsMu up on the single goroutine that
+// precedes any happy-eyeballs dial goroutines - the happens-before edge a
+// go statement gives its goroutine means those goroutines see it already
+// initialized, so the fast path in lockAttempts below needs no locking at
+// all. attemptsMuInit only matters for the fallback path, stopping two
+// such direct callers from each creating their own, non-cooperating mutex.
+var attemptsMuInit sync.Mutex
+
+// lockAttempts returns the *sync.Mutex guarding r.Attempts and friends,
+// creating it first if necessary, and locks it before returning.
+func (r *Result) lockAttempts() *sync.Mutex {
+	if r.attemptsMu != nil {
+		r.attemptsMu.Lock()
+		return r.attemptsMu
+	}
+
+	attemptsMuInit.Lock()
+	if r.attemptsMu == nil {
+		r.attemptsMu = new(sync.Mutex)
+	}
+	mu := r.attemptsMu
+	attemptsMuInit.Unlock()
+
+	mu.Lock()
+	return mu
+}
+
+func (o *ResultObserver) OnGetConn(e GetConnEvent) {
+	r := o.Result
+	r.getConnStart = e.At
+
+	// Create attemptsMu here, on the single goroutine that always fires
+	// GetConn before any dial goroutines are spawned, so the later
+	// happy-eyeballs hooks find it already set up.
+	r.lockAttempts().Unlock()
+}
+
+func (o *ResultObserver) OnGotConn(e GotConnEvent) {
+	r := o.Result
+
+	// Handle when keep alive is used and connection is reused.
+	// DNSStart(Done) and ConnectStart(Done) is skipped
+	if e.Reused {
+		r.isReused = true
+	}
+
+	r.RemoteAddr = e.RemoteAddr
+	r.LocalAddr = e.LocalAddr
+
+	if r.Proto == "" {
+		if e.Reused {
+			// TLSHandshakeStart/Done never re-fire for a reused
+			// connection, so read its negotiated state directly off the
+			// connection itself rather than guessing.
+			if tc, ok := e.Conn.(tlsConnectionStater); ok {
+				state := tc.ConnectionState()
+				r.isTLS = true
+				r.TLSVersion = state.Version
+				r.TLSCipherSuite = state.CipherSuite
+				r.NegotiatedProtocol = state.NegotiatedProtocol
+				r.ServerName = state.ServerName
+				r.PeerCertificates = state.PeerCertificates
+				if state.NegotiatedProtocol == "h2" {
+					r.Proto = "HTTP/2.0"
+				} else {
+					r.Proto = "HTTP/1.1"
+				}
+			}
+		}
+		if r.Proto == "" {
+			r.Proto = "HTTP/1.1"
+		}
+	}
+
+	if !r.getConnStart.IsZero() {
+		r.ConnAcquire = e.At.Sub(r.getConnStart)
+	}
+	r.WasIdle = e.WasIdle
+	r.IdleTime = e.IdleTime
+}
+
+func (o *ResultObserver) OnDNSStart(e DNSStartEvent) {
+	r := o.Result
+	r.dnsStart = e.At
+	r.t0 = r.dnsStart
+}
+
+func (o *ResultObserver) OnDNSDone(e DNSDoneEvent) {
+	r := o.Result
+	r.dnsDone = e.At
+
+	r.DNSLookup = r.dnsDone.Sub(r.dnsStart)
+	r.NameLookup = r.dnsDone.Sub(r.dnsStart)
+
+	r.DNSAddrs = e.Addrs
+	r.DNSCoalesced = e.Coalesced
+}
+
+func (o *ResultObserver) OnConnectStart(e ConnectStartEvent) {
+	r := o.Result
+
+	// Happy-eyeballs dials ConnectStart/ConnectDone for several addresses
+	// concurrently, from separate goroutines, so everything below -
+	// including tcpStart/dnsStart, not just Attempts/connAttempt - needs
+	// to be guarded against a second address's attempt running at the
+	// same time.
+	mu := r.lockAttempts()
+	defer mu.Unlock()
+
+	r.tcpStart = e.At
+
+	// When connecting to IP (When no DNS lookup)
+	if r.dnsStart.IsZero() {
+		r.dnsStart = r.tcpStart
+		r.dnsDone = r.tcpStart
+	}
+
+	r.Attempts = append(r.Attempts, ConnectAttempt{
+		Network: e.Network,
+		Addr:    e.Addr,
+		Start:   r.tcpStart,
+	})
+
+	if r.connAttempt == nil {
+		r.connAttempt = make(map[string]int)
+	}
+	r.connAttempt[e.Addr] = len(r.Attempts) - 1
+}
+
+func (o *ResultObserver) OnConnectDone(e ConnectDoneEvent) {
+	r := o.Result
+
+	mu := r.lockAttempts()
+	defer mu.Unlock()
+
+	r.tcpDone = e.At
+
+	r.TCPConnection = r.tcpDone.Sub(r.tcpStart)
+	r.Connect = r.tcpDone.Sub(r.dnsStart)
+
+	if i, ok := r.connAttempt[e.Addr]; ok {
+		r.Attempts[i].End = r.tcpDone
+		r.Attempts[i].Err = e.Err
+
+		if e.Err == nil {
+			r.tlsAttemptIdx = i
+			r.hasTLSAttempt = true
+		}
+	}
+}
+
+func (o *ResultObserver) OnTLSHandshakeStart(e TLSHandshakeStartEvent) {
+	r := o.Result
+	r.isTLS = true
+	r.tlsStart = e.At
+
+	mu := r.lockAttempts()
+	if r.hasTLSAttempt {
+		r.Attempts[r.tlsAttemptIdx].TLSStart = r.tlsStart
+	}
+	mu.Unlock()
+}
+
+func (o *ResultObserver) OnTLSHandshakeDone(e TLSHandshakeDoneEvent) {
+	r := o.Result
+	r.tlsDone = e.At
+
+	r.TLSHandshake = r.tlsDone.Sub(r.tlsStart)
+	r.Pretransfer = r.tlsDone.Sub(r.dnsStart)
+
+	mu := r.lockAttempts()
+	if r.hasTLSAttempt {
+		r.Attempts[r.tlsAttemptIdx].TLSDone = r.tlsDone
+	}
+	mu.Unlock()
+
+	r.TLSVersion = e.State.Version
+	r.TLSCipherSuite = e.State.CipherSuite
+	r.NegotiatedProtocol = e.State.NegotiatedProtocol
+	r.ServerName = e.State.ServerName
+	r.PeerCertificates = e.State.PeerCertificates
+
+	if e.State.NegotiatedProtocol == "h2" {
+		r.Proto = "HTTP/2.0"
+	} else {
+		r.Proto = "HTTP/1.1"
+	}
+}
+
+func (o *ResultObserver) OnWroteRequest(e WroteRequestEvent) {
+	r := o.Result
+	r.serverStart = e.At
+
+	if !r.getConnStart.IsZero() {
+		r.RequestWrite = r.serverStart.Sub(r.getConnStart)
+	}
+
+	// When client doesn't use DialContext or using old (before go1.7) `net`
+	// pakcage, DNS/TCP/TLS hook is not called.
+	if r.dnsStart.IsZero() && r.tcpStart.IsZero() {
+		now := r.serverStart
+
+		r.dnsStart = now
+		r.dnsDone = now
+		r.tcpStart = now
+		r.tcpDone = now
+	}
+
+	// When connection is re-used, DNS/TCP/TLS hook is not called.
+	if r.isReused {
+		now := r.serverStart
+
+		r.dnsStart = now
+		r.dnsDone = now
+		r.tcpStart = now
+		r.tcpDone = now
+		r.tlsStart = now
+		r.tlsDone = now
+	}
+
+	if r.isTLS {
+		return
+	}
+
+	r.TLSHandshake = r.tcpDone.Sub(r.tcpDone)
+	r.Pretransfer = r.Connect
+}
+
+func (o *ResultObserver) OnPutIdleConn(e PutIdleConnEvent) {
+	o.Result.PutIdleConnErr = e.Err
+}
+
+func (o *ResultObserver) OnWait100Continue(e Wait100ContinueEvent) {
+	o.Result.wait100Start = e.At
+}
+
+func (o *ResultObserver) OnGot100Continue(e Got100ContinueEvent) {
+	r := o.Result
+	if !r.wait100Start.IsZero() {
+		r.Wait100 = e.At.Sub(r.wait100Start)
+	}
+}
+
+func (o *ResultObserver) OnGot1xxResponse(e Got1xxResponseEvent) error {
+	r := o.Result
+	r.Informational = append(r.Informational, InformationalResponse{
+		Code:   e.Code,
+		Header: e.Header,
+		At:     e.At,
+	})
+	return nil
+}
+
+func (o *ResultObserver) OnFirstByte(e FirstByteEvent) {
+	r := o.Result
+	r.serverDone = e.At
+
+	r.ServerProcessing = r.serverDone.Sub(r.serverStart)
+	r.StartTransfer = r.serverDone.Sub(r.dnsStart)
+
+	r.transferStart = r.serverDone
+}
+
+func (o *ResultObserver) OnEnd(e EndEvent) {
+	o.Result.End(e.At)
+}
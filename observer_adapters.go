@@ -0,0 +1,191 @@
+// +build go1.8
+
+package httpstat
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// stdLogger is what LogObserver falls back to when Logger is nil.
+// log.Default(), which would otherwise be the obvious choice, didn't
+// exist before Go 1.16, and this file (like the rest of the package)
+// still supports go1.8 through go1.15.
+var stdLogger = log.New(os.Stderr, "", log.LstdFlags)
+
+// LogObserver logs trace events through a *log.Logger (or a package
+// default if Logger is nil), one line per event. It logs DNS start/done,
+// connect start/done, TLS handshake done, first response byte and end;
+// the other events are not logged. It's a cheap way to see
+// what a request is doing without wiring up a real metrics backend.
+type LogObserver struct {
+	BaseObserver
+
+	Logger *log.Logger
+}
+
+func (o *LogObserver) logger() *log.Logger {
+	if o.Logger != nil {
+		return o.Logger
+	}
+	return stdLogger
+}
+
+func (o *LogObserver) OnDNSStart(e DNSStartEvent) {
+	o.logger().Printf("httpstat: dns lookup start")
+}
+
+func (o *LogObserver) OnDNSDone(e DNSDoneEvent) {
+	o.logger().Printf("httpstat: dns lookup done, addrs=%v err=%v", e.Addrs, e.Err)
+}
+
+func (o *LogObserver) OnConnectStart(e ConnectStartEvent) {
+	o.logger().Printf("httpstat: connect start %s %s", e.Network, e.Addr)
+}
+
+func (o *LogObserver) OnConnectDone(e ConnectDoneEvent) {
+	o.logger().Printf("httpstat: connect done %s %s err=%v", e.Network, e.Addr, e.Err)
+}
+
+func (o *LogObserver) OnTLSHandshakeDone(e TLSHandshakeDoneEvent) {
+	o.logger().Printf("httpstat: tls handshake done, proto=%s err=%v", e.State.NegotiatedProtocol, e.Err)
+}
+
+func (o *LogObserver) OnFirstByte(e FirstByteEvent) {
+	o.logger().Printf("httpstat: got first response byte")
+}
+
+func (o *LogObserver) OnEnd(e EndEvent) {
+	o.logger().Printf("httpstat: request done")
+}
+
+// OTelSpan is the subset of go.opentelemetry.io/otel/trace.Span that
+// OTelObserver needs. A real trace.Span satisfies it through the adapter
+// shown in OTelObserver's doc comment; keeping the dependency out of this
+// package's own imports means using OTelObserver doesn't force every
+// go-httpstat caller to vendor the otel SDK.
+type OTelSpan interface {
+	AddEvent(name string, attrs map[string]string)
+}
+
+// OTelObserver maps httpstat trace events onto OpenTelemetry span
+// events. Wire a real span in with an adapter, e.g.:
+//
+//	type spanAdapter struct{ trace.Span }
+//
+//	func (s spanAdapter) AddEvent(name string, attrs map[string]string) {
+//		kvs := make([]attribute.KeyValue, 0, len(attrs))
+//		for k, v := range attrs {
+//			kvs = append(kvs, attribute.String(k, v))
+//		}
+//		s.Span.AddEvent(name, trace.WithAttributes(kvs...))
+//	}
+type OTelObserver struct {
+	BaseObserver
+
+	Span OTelSpan
+}
+
+func (o *OTelObserver) OnDNSStart(e DNSStartEvent) {
+	o.Span.AddEvent("dns_start", nil)
+}
+
+func (o *OTelObserver) OnDNSDone(e DNSDoneEvent) {
+	attrs := map[string]string{}
+	if e.Err != nil {
+		attrs["error"] = e.Err.Error()
+	}
+	o.Span.AddEvent("dns_done", attrs)
+}
+
+func (o *OTelObserver) OnConnectStart(e ConnectStartEvent) {
+	o.Span.AddEvent("connect_start", map[string]string{"network": e.Network, "addr": e.Addr})
+}
+
+func (o *OTelObserver) OnConnectDone(e ConnectDoneEvent) {
+	attrs := map[string]string{"network": e.Network, "addr": e.Addr}
+	if e.Err != nil {
+		attrs["error"] = e.Err.Error()
+	}
+	o.Span.AddEvent("connect_done", attrs)
+}
+
+func (o *OTelObserver) OnTLSHandshakeDone(e TLSHandshakeDoneEvent) {
+	o.Span.AddEvent("tls_handshake_done", map[string]string{"negotiated_protocol": e.State.NegotiatedProtocol})
+}
+
+func (o *OTelObserver) OnFirstByte(e FirstByteEvent) {
+	o.Span.AddEvent("first_byte", nil)
+}
+
+func (o *OTelObserver) OnEnd(e EndEvent) {
+	o.Span.AddEvent("end", nil)
+}
+
+// HistogramObserver is the subset of
+// github.com/prometheus/client_golang/prometheus.Histogram (or
+// .Observer) that PrometheusObserver needs, so this package doesn't need
+// a hard dependency on the prometheus client.
+type HistogramObserver interface {
+	Observe(float64)
+}
+
+// PrometheusObserver records the dns_lookup, tcp_connection, tls_handshake
+// and server_processing phase durations, in seconds, into a histogram
+// keyed by phase name; content transfer is not recorded. Wire it up with
+// a *prometheus.HistogramVec:
+//
+//	vec := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "http_phase_seconds"}, []string{"phase"})
+//	obs := &httpstat.PrometheusObserver{Histogram: func(phase string) httpstat.HistogramObserver {
+//		return vec.WithLabelValues(phase)
+//	}}
+type PrometheusObserver struct {
+	BaseObserver
+
+	Histogram func(phase string) HistogramObserver
+
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	serverStart  time.Time
+}
+
+func (o *PrometheusObserver) observe(phase string, d time.Duration) {
+	if o.Histogram == nil {
+		return
+	}
+	o.Histogram(phase).Observe(d.Seconds())
+}
+
+func (o *PrometheusObserver) OnDNSStart(e DNSStartEvent) {
+	o.dnsStart = e.At
+}
+
+func (o *PrometheusObserver) OnDNSDone(e DNSDoneEvent) {
+	o.observe("dns_lookup", e.At.Sub(o.dnsStart))
+}
+
+func (o *PrometheusObserver) OnConnectStart(e ConnectStartEvent) {
+	o.connectStart = e.At
+}
+
+func (o *PrometheusObserver) OnConnectDone(e ConnectDoneEvent) {
+	o.observe("tcp_connection", e.At.Sub(o.connectStart))
+}
+
+func (o *PrometheusObserver) OnTLSHandshakeStart(e TLSHandshakeStartEvent) {
+	o.tlsStart = e.At
+}
+
+func (o *PrometheusObserver) OnTLSHandshakeDone(e TLSHandshakeDoneEvent) {
+	o.observe("tls_handshake", e.At.Sub(o.tlsStart))
+}
+
+func (o *PrometheusObserver) OnWroteRequest(e WroteRequestEvent) {
+	o.serverStart = e.At
+}
+
+func (o *PrometheusObserver) OnFirstByte(e FirstByteEvent) {
+	o.observe("server_processing", e.At.Sub(o.serverStart))
+}
@@ -0,0 +1,100 @@
+// +build go1.8
+
+package httpstat
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogObserver_WritesThroughCustomLogger(t *testing.T) {
+	var buf bytes.Buffer
+	obs := &LogObserver{Logger: log.New(&buf, "", 0)}
+
+	obs.OnDNSStart(DNSStartEvent{})
+	obs.OnFirstByte(FirstByteEvent{})
+	obs.OnEnd(EndEvent{})
+
+	got := buf.String()
+	for _, want := range []string{"dns lookup start", "got first response byte", "request done"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("log output %q does not contain %q", got, want)
+		}
+	}
+}
+
+// fakeSpan is a minimal OTelSpan that records every event it receives.
+type fakeSpan struct {
+	names []string
+	attrs []map[string]string
+}
+
+func (s *fakeSpan) AddEvent(name string, attrs map[string]string) {
+	s.names = append(s.names, name)
+	s.attrs = append(s.attrs, attrs)
+}
+
+func TestOTelObserver_AddsSpanEvents(t *testing.T) {
+	span := &fakeSpan{}
+	obs := &OTelObserver{Span: span}
+
+	obs.OnDNSStart(DNSStartEvent{})
+	obs.OnConnectDone(ConnectDoneEvent{Network: "tcp", Addr: "127.0.0.1:443"})
+	obs.OnEnd(EndEvent{})
+
+	want := []string{"dns_start", "connect_done", "end"}
+	if got := len(span.names); got != len(want) {
+		t.Fatalf("len(events) = %d, want %d", got, len(want))
+	}
+	for i, name := range want {
+		if span.names[i] != name {
+			t.Fatalf("events[%d] = %s, want %s", i, span.names[i], name)
+		}
+	}
+	if got, want := span.attrs[1]["addr"], "127.0.0.1:443"; got != want {
+		t.Fatalf("connect_done addr attr = %s, want %s", got, want)
+	}
+}
+
+// fakeHistogram records every value Observe is called with.
+type fakeHistogram struct {
+	values []float64
+}
+
+func (h *fakeHistogram) Observe(v float64) { h.values = append(h.values, v) }
+
+func TestPrometheusObserver_RecordsPhaseDurations(t *testing.T) {
+	histograms := map[string]*fakeHistogram{}
+	obs := &PrometheusObserver{
+		Histogram: func(phase string) HistogramObserver {
+			h := &fakeHistogram{}
+			histograms[phase] = h
+			return h
+		},
+	}
+
+	start := time.Now()
+	obs.OnDNSStart(DNSStartEvent{At: start})
+	obs.OnDNSDone(DNSDoneEvent{At: start.Add(10 * time.Millisecond)})
+	obs.OnConnectStart(ConnectStartEvent{At: start.Add(10 * time.Millisecond)})
+	obs.OnConnectDone(ConnectDoneEvent{At: start.Add(30 * time.Millisecond)})
+
+	dns, ok := histograms["dns_lookup"]
+	if !ok {
+		t.Fatal("expected a dns_lookup histogram to be created")
+	}
+	if got, want := dns.values[0], (10 * time.Millisecond).Seconds(); got != want {
+		t.Fatalf("dns_lookup observation = %v, want %v", got, want)
+	}
+
+	tcp, ok := histograms["tcp_connection"]
+	if !ok {
+		t.Fatal("expected a tcp_connection histogram to be created")
+	}
+	if got, want := tcp.values[0], (20 * time.Millisecond).Seconds(); got != want {
+		t.Fatalf("tcp_connection observation = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,20 @@
+// +build go1.11
+
+package httpstat
+
+import (
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"time"
+)
+
+// setGot1xxResponse wires Got1xxResponse into ct, firing obs's
+// OnGot1xxResponse for every 1xx response the server sends before the
+// final one. The field only exists on httptrace.ClientTrace from Go 1.11
+// onward; see observer_pre_go111.go for the no-op used below that.
+func setGot1xxResponse(ct *httptrace.ClientTrace, obs Observer) {
+	ct.Got1xxResponse = func(code int, header textproto.MIMEHeader) error {
+		return obs.OnGot1xxResponse(Got1xxResponseEvent{At: time.Now(), Code: code, Header: http.Header(header)})
+	}
+}
@@ -0,0 +1,10 @@
+// +build go1.8,!go1.11
+
+package httpstat
+
+import "net/http/httptrace"
+
+// setGot1xxResponse is a no-op below Go 1.11: httptrace.ClientTrace has
+// no Got1xxResponse field to set, so a 1xx response never reaches
+// Observer.OnGot1xxResponse on these toolchains.
+func setGot1xxResponse(ct *httptrace.ClientTrace, obs Observer) {}
@@ -0,0 +1,87 @@
+// +build go1.8
+
+package httpstat
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptrace"
+	"testing"
+	"time"
+)
+
+type recordingObserver struct {
+	BaseObserver
+
+	events []string
+}
+
+func (o *recordingObserver) OnDNSStart(DNSStartEvent)     { o.events = append(o.events, "dns_start") }
+func (o *recordingObserver) OnDNSDone(DNSDoneEvent)       { o.events = append(o.events, "dns_done") }
+func (o *recordingObserver) OnConnectStart(ConnectStartEvent) {
+	o.events = append(o.events, "connect_start")
+}
+func (o *recordingObserver) OnConnectDone(ConnectDoneEvent) {
+	o.events = append(o.events, "connect_done")
+}
+func (o *recordingObserver) OnFirstByte(FirstByteEvent) { o.events = append(o.events, "first_byte") }
+func (o *recordingObserver) OnEnd(EndEvent)             { o.events = append(o.events, "end") }
+
+func TestWithHTTPStatObserver(t *testing.T) {
+	obs := &recordingObserver{}
+	ctx := WithHTTPStatObserver(context.Background(), obs)
+	trace := httptrace.ContextClientTrace(ctx)
+
+	trace.DNSStart(httptrace.DNSStartInfo{})
+	trace.DNSDone(httptrace.DNSDoneInfo{})
+	trace.ConnectStart("tcp", "127.0.0.1:443")
+	trace.ConnectDone("tcp", "127.0.0.1:443", nil)
+	trace.GotFirstResponseByte()
+	EndObserve(obs, time.Now())
+
+	want := []string{"dns_start", "dns_done", "connect_start", "connect_done", "first_byte", "end"}
+	if got := fmt.Sprint(obs.events); fmt.Sprint(want) != got {
+		t.Fatalf("events = %v, want %v", obs.events, want)
+	}
+}
+
+func TestResultObserver_MatchesWithHTTPStat(t *testing.T) {
+	var viaObserver Result
+	ctx := WithHTTPStatObserver(context.Background(), &ResultObserver{Result: &viaObserver})
+	trace := httptrace.ContextClientTrace(ctx)
+
+	trace.DNSStart(httptrace.DNSStartInfo{})
+	trace.DNSDone(httptrace.DNSDoneInfo{})
+	trace.ConnectStart("tcp", "127.0.0.1:443")
+	trace.ConnectDone("tcp", "127.0.0.1:443", nil)
+	trace.GotFirstResponseByte()
+	viaObserver.End(time.Now())
+
+	if viaObserver.DNSLookup <= 0 {
+		t.Fatal("expect DNSLookup to be set")
+	}
+	if viaObserver.TCPConnection <= 0 {
+		t.Fatal("expect TCPConnection to be set")
+	}
+	if got, want := len(viaObserver.Attempts), 1; got != want {
+		t.Fatalf("len(Attempts) = %d, want %d", got, want)
+	}
+
+	// The same events fed through WithHTTPStat must record the same
+	// connect attempts.
+	var viaStat Result
+	trace = httptrace.ContextClientTrace(WithHTTPStat(context.Background(), &viaStat))
+
+	trace.DNSStart(httptrace.DNSStartInfo{})
+	trace.DNSDone(httptrace.DNSDoneInfo{})
+	trace.ConnectStart("tcp", "127.0.0.1:443")
+	trace.ConnectDone("tcp", "127.0.0.1:443", nil)
+	trace.GotFirstResponseByte()
+
+	if got, want := len(viaStat.Attempts), len(viaObserver.Attempts); got != want {
+		t.Fatalf("len(Attempts) via WithHTTPStat = %d, want %d", got, want)
+	}
+	if got, want := viaStat.Attempts[0].Addr, viaObserver.Attempts[0].Addr; got != want {
+		t.Fatalf("Attempts[0].Addr via WithHTTPStat = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,130 @@
+// +build go1.8
+
+package httpstat
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Trace holds httpstat info for every hop of a redirect chain.
+// http.Client.Do transparently follows redirects, re-entering the
+// httptrace hooks for each new request; a plain Result would have each
+// hop clobber the previous one's timings. Trace instead keeps a separate
+// Result per hop.
+type Trace struct {
+	// Hops holds one Result per request sent, in the order they were
+	// sent. The last element is always the in-progress (or most recently
+	// finished) hop, kept up to date as its trace events fire; callers
+	// don't need to wait for a redirect or call Total to see it.
+	Hops []Result
+
+	current    *Result
+	hopDone    bool
+	pendingURL string
+}
+
+// WithHTTPTrace is a wrapper of httptrace.WithClientTrace that survives
+// redirects. It appends a fresh Result to Trace.Hops each time the client
+// starts a new request, detected by a GetConn firing after the previous
+// hop already received its first response byte. req labels the first
+// hop with its URL; req may be nil if the caller has no *http.Request
+// handy yet, but then Hops[0].URL stays empty unless a redirect happens
+// to back-fill it (see CheckRedirect).
+//
+// To label each subsequent hop with its URL, assign Trace.CheckRedirect
+// to http.Client.CheckRedirect (wrapping it first if the caller needs its
+// own redirect policy).
+func WithHTTPTrace(ctx context.Context, req *http.Request) (context.Context, *Trace) {
+	t := &Trace{}
+	if req != nil {
+		t.pendingURL = req.URL.String()
+	}
+	t.startHop()
+
+	obs := &hopObserver{ResultObserver: &ResultObserver{Result: t.current}, t: t}
+	return WithHTTPStatObserver(ctx, obs), t
+}
+
+// hopObserver is the Observer WithHTTPTrace feeds into
+// WithHTTPStatObserver. It delegates every event to a ResultObserver
+// pointed at the current hop, except the two that mark a hop boundary.
+type hopObserver struct {
+	*ResultObserver
+	t *Trace
+}
+
+func (h *hopObserver) OnGetConn(e GetConnEvent) {
+	h.t.advance()
+	h.ResultObserver.Result = h.t.current
+	h.ResultObserver.OnGetConn(e)
+}
+
+func (h *hopObserver) OnFirstByte(e FirstByteEvent) {
+	h.ResultObserver.OnFirstByte(e)
+	h.t.hopDone = true
+}
+
+// startHop appends a fresh Result to Hops and points current at it in
+// place, so every update made through current (via the ResultObserver
+// delegated to it) is immediately visible through Hops without a
+// separate append later.
+func (t *Trace) startHop() {
+	t.Hops = append(t.Hops, Result{URL: t.pendingURL})
+	t.pendingURL = ""
+	t.hopDone = false
+	t.current = &t.Hops[len(t.Hops)-1]
+}
+
+func (t *Trace) advance() {
+	if !t.hopDone {
+		return
+	}
+
+	t.current.End(time.Now())
+	t.startHop()
+}
+
+// CheckRedirect records the URL of each hop reached via a redirect. It
+// has the signature of http.Client.CheckRedirect so it can be assigned to
+// it directly; it always allows the redirect (returns nil). It also
+// back-fills Hops[0].URL from the original request on the first
+// redirect, in case WithHTTPTrace was called without one.
+func (t *Trace) CheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) == 1 && len(t.Hops) == 1 && t.current.URL == "" {
+		t.current.URL = via[0].URL.String()
+	}
+
+	t.pendingURL = req.URL.String()
+	return nil
+}
+
+// Total returns the sum of every hop's total duration. at must be the time
+// after the final hop's response body has been read; it is used to
+// finish the last, still in-progress hop the same way Result.End does.
+func (t *Trace) Total(at time.Time) time.Duration {
+	if t.current != nil {
+		t.current.End(at)
+		t.current = nil
+	}
+
+	var sum time.Duration
+	for _, h := range t.Hops {
+		sum += h.total
+	}
+	return sum
+}
+
+// Format renders every hop, each one labeled with its URL (see
+// CheckRedirect).
+func (t Trace) Format(s fmt.State, verb rune) {
+	for i, h := range t.Hops {
+		fmt.Fprintf(s, "Hop %d: %s\n", i+1, h.URL)
+		h.Format(s, verb)
+		if i != len(t.Hops)-1 {
+			fmt.Fprintln(s)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// +build go1.8
+
+package httpstat
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestWithHTTPTrace_Redirect(t *testing.T) {
+	req1, _ := http.NewRequest("GET", "http://example.com/", nil)
+	ctx, trace := WithHTTPTrace(context.Background(), req1)
+	ct := httptrace.ContextClientTrace(ctx)
+
+	// First hop.
+	ct.DNSStart(httptrace.DNSStartInfo{})
+	ct.DNSDone(httptrace.DNSDoneInfo{})
+	ct.ConnectStart("tcp", "127.0.0.1:80")
+	ct.ConnectDone("tcp", "127.0.0.1:80", nil)
+	ct.GotFirstResponseByte()
+
+	// http.Client calls CheckRedirect right before re-entering the trace
+	// hooks for the redirected request.
+	req2, _ := http.NewRequest("GET", "http://example.com/other", nil)
+	if err := trace.CheckRedirect(req2, []*http.Request{req1}); err != nil {
+		t.Fatalf("CheckRedirect failed: %v", err)
+	}
+
+	// Second hop.
+	ct.GetConn("127.0.0.1:80")
+	ct.DNSStart(httptrace.DNSStartInfo{})
+	ct.DNSDone(httptrace.DNSDoneInfo{})
+	ct.ConnectStart("tcp", "127.0.0.1:80")
+	ct.ConnectDone("tcp", "127.0.0.1:80", nil)
+	ct.GotFirstResponseByte()
+
+	if got, want := trace.Total(time.Now()), time.Duration(0); got <= want {
+		t.Fatalf("Total = %d, want > %d", got, want)
+	}
+
+	if got, want := len(trace.Hops), 2; got != want {
+		t.Fatalf("len(Hops) = %d, want %d", got, want)
+	}
+
+	if got, want := trace.Hops[0].URL, (&url.URL{Scheme: "http", Host: "example.com", Path: "/"}).String(); got != want {
+		t.Fatalf("Hops[0].URL = %s, want %s", got, want)
+	}
+	if got, want := trace.Hops[1].URL, (&url.URL{Scheme: "http", Host: "example.com", Path: "/other"}).String(); got != want {
+		t.Fatalf("Hops[1].URL = %s, want %s", got, want)
+	}
+}
+
+func TestWithHTTPTrace_NoRedirect(t *testing.T) {
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	ctx, trace := WithHTTPTrace(context.Background(), req)
+	ct := httptrace.ContextClientTrace(ctx)
+
+	ct.DNSStart(httptrace.DNSStartInfo{})
+	ct.DNSDone(httptrace.DNSDoneInfo{})
+	ct.ConnectStart("tcp", "127.0.0.1:80")
+	ct.ConnectDone("tcp", "127.0.0.1:80", nil)
+	ct.GotFirstResponseByte()
+
+	// Without a redirect (so advance() never runs) and without calling
+	// Total, the single hop must already be visible through Hops and
+	// Format.
+	if got, want := len(trace.Hops), 1; got != want {
+		t.Fatalf("len(Hops) = %d, want %d", got, want)
+	}
+
+	// A request with no redirect never calls CheckRedirect, so hop 1's
+	// URL has to come from the req passed to WithHTTPTrace, not a
+	// redirect-only back-fill.
+	if got, want := trace.Hops[0].URL, req.URL.String(); got != want {
+		t.Fatalf("Hops[0].URL = %s, want %s", got, want)
+	}
+
+	if got := fmt.Sprintf("%+v", trace); got == "" {
+		t.Fatalf("Format produced no output before Total was called")
+	}
+}